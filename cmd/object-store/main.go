@@ -0,0 +1,48 @@
+// Command object-store starts the HTTP and gRPC APIs against a single
+// shared service.Service, built from one store.Config, so a session
+// started over one transport is visible on the other.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	grpcapi "gitlab.com/contextualcode/go-object-store/grpc"
+	httpapi "gitlab.com/contextualcode/go-object-store/http"
+	"gitlab.com/contextualcode/go-object-store/service"
+	"gitlab.com/contextualcode/go-object-store/store"
+)
+
+func main() {
+	httpPort := flag.Int("http-port", 8080, "HTTP API port")
+	grpcPort := flag.Int("grpc-port", 8081, "gRPC API port")
+	defaultDeadline := flag.Duration("default-deadline", 30*time.Second, "default per-request deadline")
+	maxDeadline := flag.Duration("max-deadline", 5*time.Minute, "maximum per-request deadline a caller may request")
+	cookieSessions := flag.Bool("cookie-sessions", false, "issue session cookies + XSRF tokens instead of returning bearer keys")
+	allowBearerAuth := flag.Bool("allow-bearer-auth", true, "accept a bare bearer key alongside (or instead of) cookie sessions")
+	flag.Parse()
+
+	config := &store.Config{
+		HTTP: store.HTTPConfig{
+			Port:            *httpPort,
+			DefaultDeadline: *defaultDeadline,
+			MaxDeadline:     *maxDeadline,
+			CookieSessions:  *cookieSessions,
+			AllowBearerAuth: *allowBearerAuth,
+		},
+		GRPC: store.GRPCConfig{
+			Port: *grpcPort,
+		},
+	}
+
+	svc, err := service.New(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- httpapi.Listen(svc, config) }()
+	go func() { errCh <- grpcapi.Listen(svc, config) }()
+	log.Fatal(<-errCh)
+}
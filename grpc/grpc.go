@@ -0,0 +1,187 @@
+// Package grpc exposes the same Login/Get/Set/Delete/Query operations as
+// the http package, over gRPC. Both subsystems delegate all business logic
+// to service.Service; this package only translates protobuf messages into
+// the request structs that service expects and maps store errors to gRPC
+// status codes.
+//
+// grpc/pb is generated from objectstore.proto and gitignored; run `go
+// generate` (or `make generate`) after checkout, and whenever
+// objectstore.proto changes, to produce it.
+//
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative objectstore.proto
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"gitlab.com/contextualcode/go-object-store/grpc/pb"
+	"gitlab.com/contextualcode/go-object-store/service"
+	"gitlab.com/contextualcode/go-object-store/store"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// Server implements pb.ObjectStoreServer on top of a shared service.Service.
+type Server struct {
+	pb.UnimplementedObjectStoreServer
+	svc *service.Service
+}
+
+// Listen starts the gRPC API server against svc. svc is typically shared
+// with http.Listen (both built from the same store.Config), so a login
+// through one transport is visible to the other, typically from its own
+// goroutine.
+func Listen(svc *service.Service, config *store.Config) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GRPC.Port))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterObjectStoreServer(srv, &Server{svc: svc})
+	if err := srv.Serve(lis); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func decodeObjects(objs []*pb.Object) ([]types.APIObject, error) {
+	out := make([]types.APIObject, 0, len(objs))
+	for _, o := range objs {
+		var apiObj types.APIObject
+		if err := json.Unmarshal(o.Json, &apiObj); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		out = append(out, apiObj)
+	}
+	return out, nil
+}
+
+func encodeObjects(objs []types.APIObject) ([]*pb.Object, error) {
+	out := make([]*pb.Object, 0, len(objs))
+	for _, o := range objs {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		out = append(out, &pb.Object{Json: data})
+	}
+	return out, nil
+}
+
+// grpcStatusError translates a store/service error into a gRPC status,
+// mirroring errHTTPResponseCode in the http package.
+func grpcStatusError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrPermission):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, store.ErrInvalidCreds), errors.Is(err, store.ErrInvalidCredientials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, store.ErrInvalidArg), errors.Is(err, store.ErrObjectNotSpecified):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Login implements pb.ObjectStoreServer.
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	_, key, err := s.svc.Login(req.Username, req.Password, "")
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	return &pb.LoginResponse{
+		Key:     key.Key,
+		Expires: key.Expires.UTC().Format("2006-01-02T15:04:05Z07:00"),
+	}, nil
+}
+
+// Get implements pb.ObjectStoreServer.
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.ObjectsResponse, error) {
+	objs, err := decodeObjects(req.Objects)
+	if err != nil {
+		return nil, err
+	}
+	respObjs, err := s.svc.Get(ctx, req.SessionKey, objs)
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	pbObjs, err := encodeObjects(respObjs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ObjectsResponse{Objects: pbObjs}, nil
+}
+
+// Set implements pb.ObjectStoreServer.
+func (s *Server) Set(ctx context.Context, req *pb.SetRequest) (*pb.ObjectsResponse, error) {
+	objs, err := decodeObjects(req.Objects)
+	if err != nil {
+		return nil, err
+	}
+	respObjs, err := s.svc.Set(ctx, req.SessionKey, objs)
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	pbObjs, err := encodeObjects(respObjs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ObjectsResponse{Objects: pbObjs}, nil
+}
+
+// Delete implements pb.ObjectStoreServer.
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	objs, err := decodeObjects(req.Objects)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.svc.Delete(ctx, req.SessionKey, objs); err != nil {
+		return nil, grpcStatusError(err)
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// Query implements pb.ObjectStoreServer.
+func (s *Server) Query(ctx context.Context, req *pb.QueryRequest) (*pb.ObjectsResponse, error) {
+	respObjs, next, err := s.svc.Query(ctx, req.SessionKey, req.Query, int(req.Limit), req.After)
+	if err != nil {
+		return nil, grpcStatusError(err)
+	}
+	pbObjs, err := encodeObjects(respObjs)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ObjectsResponse{Objects: pbObjs, NextCursor: next}, nil
+}
+
+// QueryStream implements pb.ObjectStoreServer, streaming matching objects
+// back as they're found rather than collecting the full result set first.
+func (s *Server) QueryStream(req *pb.QueryRequest, stream pb.ObjectStore_QueryStreamServer) error {
+	objCh, errCh := s.svc.QueryStream(stream.Context(), req.SessionKey, req.Query)
+	for obj := range objCh {
+		pbObj, err := encodeObjects([]types.APIObject{obj})
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(pbObj[0]); err != nil {
+			return err
+		}
+	}
+	if err := <-errCh; err != nil {
+		return grpcStatusError(err)
+	}
+	return nil
+}
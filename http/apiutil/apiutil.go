@@ -0,0 +1,145 @@
+// Package apiutil holds the HTTP transport concerns shared by every
+// versioned API surface (session/XSRF handling, deadline derivation,
+// response-status metrics), so each version package only has to own its own
+// request/response wire format and routing rather than re-deriving these on
+// every bump.
+package apiutil
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/metrics"
+	"gitlab.com/contextualcode/go-object-store/store"
+)
+
+// SessionCookieName is the cookie login sets in cookie-session mode.
+const SessionCookieName = "ObjectStore-Session"
+
+// AuthConfig mirrors store.Config.HTTP's auth-mode flags: whether login
+// issues a cookie + XSRF token instead of returning the bare key in the
+// response body, and whether the bare-key-in-body fallback (for
+// server-to-server clients that can't follow cookie semantics) stays
+// accepted alongside it.
+type AuthConfig struct {
+	CookieSessions  bool
+	AllowBearerAuth bool
+}
+
+// DeadlineConfig holds the server-side default/max deadlines applied to
+// incoming requests, taken from store.Config.HTTP.
+type DeadlineConfig struct {
+	Default time.Duration
+	Max     time.Duration
+}
+
+// SessionCookie builds the HttpOnly/Secure/SameSite=Strict cookie login
+// sets in cookie-session mode.
+func SessionCookie(key string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    key,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ClearSessionCookie builds the cookie logout sets to end a cookie session.
+func ClearSessionCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ResolveSessionKey prefers the session cookie over a key carried in the
+// request body/query string, reporting whether the cookie was used.
+func ResolveSessionKey(r *http.Request, bodyKey string) (key string, fromCookie bool) {
+	if c, err := r.Cookie(SessionCookieName); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	return bodyKey, false
+}
+
+// RequireXSRF enforces the XSRF check for mutating requests, but only when
+// auth.CookieSessions is the configured mode: a cookie-carried session must
+// then present a matching X-XSRF-Token header, and a bare bearer key is
+// only accepted at all when auth.AllowBearerAuth is also set. When
+// CookieSessions is off (the default), a bare bearer key is accepted
+// unconditionally, matching pre-cookie-session behavior. validate is called
+// to check the header token against the session's XSRF token (typically
+// service.Service.ValidateXSRF).
+func RequireXSRF(auth AuthConfig, r *http.Request, sessionKey string, fromCookie bool, validate func(sessionKey, token string) bool) error {
+	if !auth.CookieSessions {
+		return nil
+	}
+	if !fromCookie {
+		if !auth.AllowBearerAuth {
+			return errors.WithStack(store.ErrPermission)
+		}
+		return nil
+	}
+	token := r.Header.Get("X-XSRF-Token")
+	if token == "" || !validate(sessionKey, token) {
+		return errors.WithStack(store.ErrPermission)
+	}
+	return nil
+}
+
+// RequestContext derives the context a request should run under: it is
+// cancelled when the client disconnects (r.Context().Done()), and bounded by
+// a deadline — deadlineSeconds (the client-supplied value, 0 if absent)
+// clamped to d.Max, otherwise d.Default.
+func RequestContext(d DeadlineConfig, r *http.Request, deadlineSeconds int) (context.Context, context.CancelFunc) {
+	dl := d.Default
+	if deadlineSeconds > 0 {
+		dl = time.Duration(deadlineSeconds) * time.Second
+	}
+	if d.Max > 0 && dl > d.Max {
+		dl = d.Max
+	}
+	if dl <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), dl)
+}
+
+// StatusRecorder captures the status code an http.ResponseWriter was
+// written with, so handlers can report it to metrics after the fact without
+// threading it through every return path.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *StatusRecorder) WriteHeader(code int) {
+	r.Status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush forwards to the underlying ResponseWriter if it supports flushing,
+// so wrapping a recorder around it doesn't break streaming handlers.
+func (r *StatusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// RecordAPIMetrics reports a completed API request to
+// objectstore_api_requests_total/objectstore_api_request_duration_seconds.
+func RecordAPIMetrics(resource string, rec *StatusRecorder, start time.Time) {
+	metrics.APIRequestDuration.WithLabelValues(resource).Observe(time.Since(start).Seconds())
+	metrics.APIRequestsTotal.WithLabelValues(resource, strconv.Itoa(rec.Status)).Inc()
+}
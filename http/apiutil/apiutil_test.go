@@ -0,0 +1,88 @@
+package apiutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveSessionKeyPrefersCookie(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/get?key=bodykey", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "cookiekey"})
+
+	key, fromCookie := ResolveSessionKey(r, "bodykey")
+	if !fromCookie {
+		t.Error("expected fromCookie to be true")
+	}
+	if key != "cookiekey" {
+		t.Errorf("expected cookie key, got %q", key)
+	}
+}
+
+func TestResolveSessionKeyFallsBackToBodyKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/get", nil)
+
+	key, fromCookie := ResolveSessionKey(r, "bodykey")
+	if fromCookie {
+		t.Error("expected fromCookie to be false")
+	}
+	if key != "bodykey" {
+		t.Errorf("expected body key, got %q", key)
+	}
+}
+
+func TestRequireXSRFAllowsBearerByDefault(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	var auth AuthConfig // zero value: CookieSessions off, as in any deployment that hasn't opted in
+	err := RequireXSRF(auth, r, "somekey", false, func(string, string) bool { return false })
+	if err != nil {
+		t.Errorf("expected bare bearer key to be accepted when CookieSessions is off, got %v", err)
+	}
+}
+
+func TestRequireXSRFRejectsBearerWhenDisallowed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	auth := AuthConfig{CookieSessions: true, AllowBearerAuth: false}
+	err := RequireXSRF(auth, r, "somekey", false, func(string, string) bool { return true })
+	if err == nil {
+		t.Error("expected bare bearer key to be rejected")
+	}
+}
+
+func TestRequireXSRFAllowsBearerWhenEnabled(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	auth := AuthConfig{CookieSessions: true, AllowBearerAuth: true}
+	err := RequireXSRF(auth, r, "somekey", false, func(string, string) bool { return true })
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequireXSRFRejectsCookieWithoutToken(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	auth := AuthConfig{CookieSessions: true}
+	err := RequireXSRF(auth, r, "somekey", true, func(string, string) bool { return true })
+	if err == nil {
+		t.Error("expected missing X-XSRF-Token header to be rejected")
+	}
+}
+
+func TestRequireXSRFRejectsCookieWithWrongToken(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	r.Header.Set("X-XSRF-Token", "wrong")
+	auth := AuthConfig{CookieSessions: true}
+	err := RequireXSRF(auth, r, "somekey", true, func(sessionKey, token string) bool { return token == "right" })
+	if err == nil {
+		t.Error("expected wrong XSRF token to be rejected")
+	}
+}
+
+func TestRequireXSRFAcceptsCookieWithMatchingToken(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/set", nil)
+	r.Header.Set("X-XSRF-Token", "right")
+	auth := AuthConfig{CookieSessions: true}
+	err := RequireXSRF(auth, r, "somekey", true, func(sessionKey, token string) bool { return token == "right" })
+	if err != nil {
+		t.Error(err)
+	}
+}
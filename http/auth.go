@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.com/contextualcode/go-object-store/http/apiutil"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// httpAuth is this package's apiutil.AuthConfig, set once by Listen.
+var httpAuth apiutil.AuthConfig
+
+func sessionCookie(key string, expires time.Time) *http.Cookie {
+	return apiutil.SessionCookie(key, expires)
+}
+
+func clearSessionCookie() *http.Cookie {
+	return apiutil.ClearSessionCookie()
+}
+
+// resolveSessionKey prefers the session cookie over a key carried in the
+// request body/query string, reporting whether the cookie was used.
+func resolveSessionKey(r *http.Request, bodyKey string) (key string, fromCookie bool) {
+	return apiutil.ResolveSessionKey(r, bodyKey)
+}
+
+// requireXSRF enforces the XSRF check for mutating requests: a
+// cookie-carried session must present a matching X-XSRF-Token header, and a
+// bare bearer key is only accepted at all when AllowBearerAuth is set.
+func requireXSRF(r *http.Request, sessionKey string, fromCookie bool) error {
+	return apiutil.RequireXSRF(httpAuth, r, sessionKey, fromCookie, svc.ValidateXSRF)
+}
+
+// parseMutatingBody decodes the request body like parsePostBody, then
+// resolves and authorizes the session key for a mutating operation (set,
+// delete, or the POST variants of get/query).
+func parseMutatingBody(r *http.Request) (types.APIRequest, error) {
+	req, err := parsePostBody(r)
+	if err != nil {
+		return req, err
+	}
+	sessionKey, fromCookie := resolveSessionKey(r, req.SessionKey)
+	req.SessionKey = sessionKey
+	if err := requireXSRF(r, sessionKey, fromCookie); err != nil {
+		return req, err
+	}
+	return req, nil
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/store"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// maxBlobMemory is the amount of an incoming multipart/form-data blob
+// upload kept in memory before ParseMultipartForm spills the rest to disk.
+const maxBlobMemory = 32 << 20 // 32MB
+
+func blob(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		uploadBlob(w, r)
+		return
+	case http.MethodGet:
+		downloadBlob(w, r)
+		return
+	}
+	errorResponse(w, ErrAPIInvalidMethod)
+}
+
+// uploadBlob handles POST /blob: a multipart/form-data body with a
+// "metadata" part (the usual APIObject fields, JSON-encoded) and a "file"
+// part holding the raw bytes to store.
+func uploadBlob(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics("blob_upload", rec, start)
+	if err := r.ParseMultipartForm(maxBlobMemory); err != nil {
+		errorResponse(w, errors.WithStack(err))
+		return
+	}
+	var metadata types.APIObject
+	if raw := r.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			errorResponse(w, errors.WithStack(err))
+			return
+		}
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		errorResponse(w, errors.WithStack(err))
+		return
+	}
+	defer file.Close()
+
+	sessionKey, fromCookie := resolveSessionKey(r, r.FormValue("key"))
+	if err := requireXSRF(r, sessionKey, fromCookie); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	req := types.APIRequest{
+		SessionKey: sessionKey,
+		IP:         r.RemoteAddr,
+		Objects:    []types.APIObject{metadata},
+	}
+	logAPIRequest(req, types.APISet)
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+
+	obj, err := svc.SetBlob(ctx, req.SessionKey, metadata, header.Header.Get("Content-Type"), file)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, &types.APIResponse{
+		Success: true,
+		Objects: []types.APIObject{obj},
+	})
+}
+
+// downloadBlob handles GET /blob?uid=...: it streams the linked blob back
+// via http.ServeContent, which takes care of Content-Type, Content-Length
+// and Range handling given a seekable reader.
+func downloadBlob(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics("blob_download", rec, start)
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		errorResponse(w, store.ErrInvalidArg)
+		return
+	}
+	sessionKey, _ := resolveSessionKey(r, r.URL.Query().Get("key"))
+	req := types.APIRequest{SessionKey: sessionKey, IP: r.RemoteAddr}
+	logAPIRequest(req, types.APIGet)
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+
+	obj, rc, err := svc.OpenBlob(ctx, req.SessionKey, uid)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", obj.Blob.ContentType)
+	w.Header().Set("ETag", `"`+obj.Blob.SHA256+`"`)
+	http.ServeContent(w, r, uid, obj.Modified, rc)
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"gitlab.com/contextualcode/go-object-store/http/apiutil"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// httpDeadlines holds the server-side default/max deadlines applied to
+// incoming requests, taken from store.Config.HTTP at Listen time.
+var httpDeadlines apiutil.DeadlineConfig
+
+// requestContext derives the context a request should run under: it is
+// cancelled when the client disconnects (r.Context().Done()), and bounded
+// by a deadline — the client-supplied req.Deadline (seconds) if present,
+// clamped to httpDeadlines.Max, otherwise httpDeadlines.Default. Long-running
+// store operations (notably index scans in Query) check ctx.Err()
+// periodically and abort with a wrapped context.DeadlineExceeded once it
+// fires, which errHTTPResponseCode maps to 504.
+func requestContext(r *http.Request, req types.APIRequest) (context.Context, context.CancelFunc) {
+	return apiutil.RequestContext(httpDeadlines, r, req.Deadline)
+}
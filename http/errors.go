@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/store"
+)
+
+// ErrInvalidResource is returned when request() is called with an
+// unrecognised types.APIResource.
+var ErrInvalidResource = errors.New("invalid api resource")
+
+// ErrAPIInvalidMethod is returned when a handler is hit with an HTTP method
+// it doesn't support.
+var ErrAPIInvalidMethod = errors.New("invalid http method for this endpoint")
+
+// ErrEmptyReponse is logged when sendResponse is called with a nil response.
+var ErrEmptyReponse = errors.New("response is empty")
+
+// errHTTPResponseCode maps a store/service error to the HTTP status code it
+// should be reported with.
+func errHTTPResponseCode(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, store.ErrInvalidCreds), errors.Is(err, store.ErrInvalidCredientials):
+		return http.StatusUnauthorized
+	case errors.Is(err, store.ErrInvalidArg), errors.Is(err, store.ErrObjectNotSpecified), errors.Is(err, ErrInvalidResource):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAPIInvalidMethod):
+		return http.StatusMethodNotAllowed
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,45 +1,52 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/http/apiutil"
+	"gitlab.com/contextualcode/go-object-store/http/v1"
+	"gitlab.com/contextualcode/go-object-store/service"
 	"gitlab.com/contextualcode/go-object-store/store"
 	"gitlab.com/contextualcode/go-object-store/types"
 )
 
-const anonymousUser = "anonymous"
+var svc *service.Service
 
-var client *store.Client
-
-// Listen starts HTTP API server.
-func Listen(config *store.Config) error {
-	// init store
-	client = store.NewClient(config)
-	sessions = make([]*UserSession, 0)
-	// init anonymous user
-	u, _ := client.GetUserByUsername(anonymousUser)
-	if u == nil {
-		u := &types.User{
-			Username: anonymousUser,
-			Groups:   []string{anonymousUser},
-		}
-		if err := client.SetUser(u); err != nil {
-			return errors.WithStack(err)
-		}
+// Listen starts the HTTP API server against s. s is typically shared with
+// grpc.Listen so both transports see the same session state.
+func Listen(s *service.Service, config *store.Config) error {
+	svc = s
+	httpDeadlines = apiutil.DeadlineConfig{
+		Default: config.HTTP.DefaultDeadline,
+		Max:     config.HTTP.MaxDeadline,
 	}
-	// endpoints
+	httpAuth = apiutil.AuthConfig{
+		CookieSessions:  config.HTTP.CookieSessions,
+		AllowBearerAuth: config.HTTP.AllowBearerAuth,
+	}
+	// versioned API surface; v0 (below) is kept only for existing callers
+	v1.Register(s, httpDeadlines, httpAuth)
+	// deprecated unversioned endpoints, kept as aliases of /api/v1/...
 	http.HandleFunc("/login", login)
+	http.HandleFunc("/logout", logout)
 	http.HandleFunc("/set", set)
 	http.HandleFunc("/get", get)
 	http.HandleFunc("/delete", delete)
 	http.HandleFunc("/query", query)
+	http.HandleFunc("/query/stream", queryStream)
+	http.HandleFunc("/blob", blob)
+	http.Handle("/metrics", metricsHandler)
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/readyz", readyz)
 	// serve http
 	logInfo(fmt.Sprintf("Start HTTP server on port %d.", config.HTTP.Port))
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", config.HTTP.Port), nil); err != nil {
@@ -65,19 +72,6 @@ func parsePostBody(r *http.Request) (types.APIRequest, error) {
 	return apiReq, nil
 }
 
-func getUserFromSessionKey(key string) (*types.User, error) {
-	if key == "" {
-		user, err := client.GetUserByUsername(anonymousUser)
-		return user, errors.WithStack(err)
-	}
-	sess := getSessionFromKey(key)
-	if sess == nil {
-		return nil, errors.WithStack(store.ErrPermission)
-	}
-	user, err := client.GetUser(sess.UserUID)
-	return user, errors.WithStack(err)
-}
-
 func errorResponse(w http.ResponseWriter, err error) {
 	logWarnErr(err, "")
 	sendResponse(w, errHTTPResponseCode(err), &types.APIResponse{
@@ -104,79 +98,26 @@ func sendResponse(w http.ResponseWriter, status int, resp *types.APIResponse) {
 	}
 }
 
-func request(res types.APIResource, req types.APIRequest, w http.ResponseWriter) {
+// request decodes res/req into calls against the shared service layer and
+// writes the resulting HTTP response. The gRPC subsystem talks to the same
+// service.Service methods, so all business logic lives there rather than
+// here.
+func request(ctx context.Context, res types.APIResource, req types.APIRequest, w http.ResponseWriter) {
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics(string(res), rec, start)
 	// log request
 	logAPIRequest(req, res)
 	// handle request
 	switch res {
-	case types.APILogin:
-		{
-			if req.Username == "" || req.Password == "" {
-				errorResponse(w, store.ErrInvalidCreds)
-				return
-			}
-			// check username/password
-			user, err := client.GetUserByUsername(req.Username)
-			if err != nil {
-				if errors.Is(err, store.ErrNotFound) {
-					errorResponse(w, store.ErrInvalidCredientials)
-					return
-				}
-				errorResponse(w, err)
-				return
-			}
-			if !store.CheckPassword(req.Password, user.PasswordHash) {
-				errorResponse(w, store.ErrInvalidCredientials)
-				return
-			}
-			// prepare user session
-			sess, key := newSession(user, req.IP)
-			if sess == nil || key == nil {
-				errorResponse(w, store.ErrUnknown)
-				return
-			}
-			checkSessions()
-			sessions = append(sessions, sess)
-			// send response
-			sendResponse(w, http.StatusOK, &types.APIResponse{
-				Success: true,
-				Key:     key.Key,
-				Expires: key.Expires.UTC().Format(time.RFC3339),
-			})
-			return
-		}
 	case types.APIGet:
 		{
-			if len(req.Objects) == 0 {
-				errorResponse(w, store.ErrObjectNotSpecified)
-				return
-			}
-			user, err := getUserFromSessionKey(req.SessionKey)
+			respObjs, err := svc.Get(ctx, req.SessionKey, req.Objects)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			respObjs := make([]types.APIObject, 0)
-			for _, o := range req.Objects {
-				// ensure object isn't already in response
-				hasObj := false
-				for _, ro := range respObjs {
-					if ro.UID() == o.UID() {
-						hasObj = true
-						break
-					}
-				}
-				if hasObj {
-					continue
-				}
-				// fetch
-				respObj, err := client.Get(o.Object().UID, user)
-				if err != nil {
-					errorResponse(w, err)
-					return
-				}
-				respObjs = append(respObjs, respObj.API())
-			}
 			sendResponse(w, http.StatusOK, &types.APIResponse{
 				Success: true,
 				Objects: respObjs,
@@ -185,23 +126,11 @@ func request(res types.APIResource, req types.APIRequest, w http.ResponseWriter)
 		}
 	case types.APISet:
 		{
-			user, err := getUserFromSessionKey(req.SessionKey)
+			respObjs, err := svc.Set(ctx, req.SessionKey, req.Objects)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			respObjs := make([]types.APIObject, 0)
-			for _, o := range req.Objects {
-				if o == nil {
-					continue
-				}
-				fullObj := o.Object()
-				if err := client.Set(fullObj, user); err != nil {
-					errorResponse(w, err)
-					return
-				}
-				respObjs = append(respObjs, fullObj.API())
-			}
 			sendResponse(w, http.StatusOK, &types.APIResponse{
 				Success: true,
 				Objects: respObjs,
@@ -210,47 +139,26 @@ func request(res types.APIResource, req types.APIRequest, w http.ResponseWriter)
 		}
 	case types.APIDelete:
 		{
-			user, err := getUserFromSessionKey(req.SessionKey)
-			if err != nil {
+			if err := svc.Delete(ctx, req.SessionKey, req.Objects); err != nil {
 				errorResponse(w, err)
 				return
 			}
-			for _, o := range req.Objects {
-				if o == nil {
-					continue
-				}
-				if err := client.Delete(o.Object(), user); err != nil {
-					errorResponse(w, err)
-					return
-				}
-			}
 			sendResponse(w, http.StatusOK, &types.APIResponse{
 				Success: true,
 			})
+			return
 		}
 	case types.APIQuery:
 		{
-			user, err := getUserFromSessionKey(req.SessionKey)
+			respObjs, nextCursor, err := svc.Query(ctx, req.SessionKey, req.Query, req.Limit, req.After)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			if req.Query == "" {
-				errorResponse(w, store.ErrInvalidArg)
-				return
-			}
-			objs, err := client.Query(req.Query, user)
-			if err != nil {
-				errorResponse(w, err)
-				return
-			}
-			respObjs := make([]types.APIObject, 0)
-			for _, o := range objs {
-				respObjs = append(respObjs, o.API())
-			}
 			sendResponse(w, http.StatusOK, &types.APIResponse{
-				Success: true,
-				Objects: respObjs,
+				Success:    true,
+				Objects:    respObjs,
+				NextCursor: nextCursor,
 			})
 			return
 		}
@@ -258,45 +166,111 @@ func request(res types.APIResource, req types.APIRequest, w http.ResponseWriter)
 	errorResponse(w, ErrInvalidResource)
 }
 
+// login authenticates the caller and starts a session. In cookie-session
+// mode (config.HTTP.CookieSessions) the session key is set as an
+// HttpOnly/Secure/SameSite=Strict cookie and never appears in the response
+// body; callers instead get an XSRF token to echo back via the
+// X-XSRF-Token header on mutating requests. Otherwise (or when
+// AllowBearerAuth is also set) the key is returned in the body as before,
+// for server-to-server clients that can't rely on cookies.
+//
+// Deprecated: use /api/v1/login. Kept as an alias with the legacy response
+// envelope for existing callers.
 func login(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		{
-			req, err := parsePostBody(r)
-			if err != nil {
-				errorResponse(w, err)
-				return
-			}
-			request(types.APILogin, req, w)
-			return
-		}
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics("login", rec, start)
+	if r.Method != http.MethodPost {
+		errorResponse(w, ErrAPIInvalidMethod)
+		return
 	}
-	errorResponse(w, ErrAPIInvalidMethod)
+	req, err := parsePostBody(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	logAPIRequest(req, types.APILogin)
+	_, key, err := svc.Login(req.Username, req.Password, req.IP)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	resp := &types.APIResponse{
+		Success: true,
+		Expires: key.Expires.UTC().Format(time.RFC3339),
+	}
+	if httpAuth.CookieSessions {
+		http.SetCookie(w, sessionCookie(key.Key, key.Expires))
+		resp.XSRFToken = key.XSRF
+	}
+	if !httpAuth.CookieSessions || httpAuth.AllowBearerAuth {
+		resp.Key = key.Key
+	}
+	sendResponse(w, http.StatusOK, resp)
 }
 
+// logout invalidates the caller's session and, in cookie-session mode,
+// clears the session cookie.
+func logout(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics("logout", rec, start)
+	if r.Method != http.MethodPost {
+		errorResponse(w, ErrAPIInvalidMethod)
+		return
+	}
+	req, err := parsePostBody(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	logAPIRequest(req, types.APILogin)
+	sessionKey, fromCookie := resolveSessionKey(r, req.SessionKey)
+	if err := svc.Logout(sessionKey); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	if fromCookie {
+		http.SetCookie(w, clearSessionCookie())
+	}
+	sendResponse(w, http.StatusOK, &types.APIResponse{Success: true})
+}
+
+// set handles POST/PUT /set.
+//
+// Deprecated: use /api/v1/set.
 func set(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost, http.MethodPut:
 		{
-			req, err := parsePostBody(r)
+			req, err := parseMutatingBody(r)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			request(types.APISet, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APISet, req, w)
 			return
 		}
 	}
 	errorResponse(w, ErrAPIInvalidMethod)
 }
 
+// get handles GET/POST /get.
+//
+// Deprecated: use /api/v1/get, which also tolerates partial failures
+// (missing objects are reported as warnings rather than failing the batch).
 func get(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		{
 			uids := strings.Split(r.URL.Query().Get("uid"), ",")
+			sessionKey, _ := resolveSessionKey(r, r.URL.Query().Get("key"))
 			req := types.APIRequest{
-				SessionKey: r.URL.Query().Get("key"),
+				SessionKey: sessionKey,
 				Objects:    make([]types.APIObject, 0),
 			}
 			for _, uid := range uids {
@@ -304,39 +278,51 @@ func get(w http.ResponseWriter, r *http.Request) {
 					req.Objects = append(req.Objects, types.APIObject{"_uid": uid})
 				}
 			}
-			request(types.APIGet, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APIGet, req, w)
 			return
 		}
 	case http.MethodPost:
 		{
-			req, err := parsePostBody(r)
+			req, err := parseMutatingBody(r)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			request(types.APIGet, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APIGet, req, w)
 			return
 		}
 	}
 	errorResponse(w, ErrAPIInvalidMethod)
 }
 
+// delete handles POST/DELETE /delete.
+//
+// Deprecated: use /api/v1/delete.
 func delete(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost, http.MethodDelete:
 		{
-			req, err := parsePostBody(r)
+			req, err := parseMutatingBody(r)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			request(types.APIDelete, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APIDelete, req, w)
 			return
 		}
 	}
 	errorResponse(w, ErrAPIInvalidMethod)
 }
 
+// query handles GET/POST /query.
+//
+// Deprecated: use /api/v1/query.
 func query(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -349,23 +335,80 @@ func query(w http.ResponseWriter, r *http.Request) {
 				errorResponse(w, store.ErrInvalidArg)
 				return
 			}
+			sessionKey, _ := resolveSessionKey(r, r.URL.Query().Get("key"))
 			req := types.APIRequest{
-				SessionKey: r.URL.Query().Get("key"),
+				SessionKey: sessionKey,
 				Query:      q,
+				After:      r.URL.Query().Get("after"),
+			}
+			if l := r.URL.Query().Get("limit"); l != "" {
+				if limit, err := strconv.Atoi(l); err == nil {
+					req.Limit = limit
+				}
 			}
-			request(types.APIQuery, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APIQuery, req, w)
 			return
 		}
 	case http.MethodPost:
 		{
-			req, err := parsePostBody(r)
+			req, err := parseMutatingBody(r)
 			if err != nil {
 				errorResponse(w, err)
 				return
 			}
-			request(types.APIQuery, req, w)
+			ctx, cancel := requestContext(r, req)
+			defer cancel()
+			request(ctx, types.APIQuery, req, w)
 			return
 		}
 	}
 	errorResponse(w, ErrAPIInvalidMethod)
 }
+
+// queryStream handles GET /query/stream, writing matching objects as
+// newline-delimited JSON (one APIObject per line) and flushing after each
+// one so clients can process results as they arrive instead of waiting for
+// the full result set.
+func queryStream(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer recordAPIMetrics("query_stream", rec, start)
+	if r.Method != http.MethodGet {
+		errorResponse(w, ErrAPIInvalidMethod)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		q = r.URL.Query().Get("query")
+	}
+	if q == "" {
+		errorResponse(w, store.ErrInvalidArg)
+		return
+	}
+	sessionKey, _ := resolveSessionKey(r, r.URL.Query().Get("key"))
+	req := types.APIRequest{SessionKey: sessionKey, Query: q}
+	logAPIRequest(req, types.APIQuery)
+
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+	objCh, errCh := svc.QueryStream(ctx, sessionKey, q)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	for obj := range objCh {
+		if err := enc.Encode(obj); err != nil {
+			logWarnErr(err, "failed to encode streamed object")
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if err := <-errCh; err != nil {
+		logWarnErr(err, "query stream ended with error")
+	}
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"log"
+	"strings"
+
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// logInfo logs an informational message, e.g. server startup.
+func logInfo(msg string) {
+	log.Print(msg)
+}
+
+// logWarnErr logs err, prefixed with msg if it's non-empty. A nil err is a
+// no-op, so callers can use it unconditionally on error-handling paths.
+func logWarnErr(err error, msg string) {
+	if err == nil {
+		return
+	}
+	if msg == "" {
+		log.Printf("warning: %v", err)
+		return
+	}
+	log.Printf("warning: %s: %v", msg, err)
+}
+
+// logAPIRequest logs that a request for res was received, identified by its
+// IP. req.Password is deliberately never logged.
+func logAPIRequest(req types.APIRequest, res types.APIResource) {
+	log.Printf("api request resource=%s ip=%s", res, req.IP)
+}
+
+// sanitizeValues trims surrounding whitespace from the string fields of req
+// a caller might reasonably have submitted with stray whitespace (e.g.
+// pasted from elsewhere), so that fields like Username/SessionKey compare
+// correctly downstream. Password is left untouched.
+func sanitizeValues(req *types.APIRequest) {
+	req.Username = strings.TrimSpace(req.Username)
+	req.SessionKey = strings.TrimSpace(req.SessionKey)
+	req.Query = strings.TrimSpace(req.Query)
+	req.After = strings.TrimSpace(req.After)
+}
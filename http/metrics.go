@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gitlab.com/contextualcode/go-object-store/http/apiutil"
+)
+
+// statusRecorder captures the status code an http.ResponseWriter was
+// written with, so handlers can report it to metrics after the fact
+// without threading it through every return path.
+type statusRecorder = apiutil.StatusRecorder
+
+// recordAPIMetrics reports a completed API request to
+// objectstore_api_requests_total/objectstore_api_request_duration_seconds.
+func recordAPIMetrics(resource string, rec *statusRecorder, start time.Time) {
+	apiutil.RecordAPIMetrics(resource, rec, start)
+}
+
+// healthz always reports healthy once the HTTP server is serving.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyz reports ready only after the first successful store sync, so a
+// freshly started instance isn't sent traffic before its index is usable.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if !svc.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// metricsHandler exposes the Prometheus registry at /metrics.
+var metricsHandler = promhttp.Handler()
@@ -0,0 +1,330 @@
+// Package v1 implements the versioned /api/v1/... API surface. It is a
+// thin transport layer over service.Service, same as the legacy (now
+// deprecated) unversioned routes in the http package — but it owns its own
+// request decoding and response envelope so it can evolve independently of
+// whatever v2 eventually looks like.
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/contextualcode/go-object-store/http/apiutil"
+	"gitlab.com/contextualcode/go-object-store/service"
+	"gitlab.com/contextualcode/go-object-store/store"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// errInvalidMethod is returned when a handler is hit with an HTTP method it
+// doesn't support.
+var errInvalidMethod = errors.New("invalid http method for this endpoint")
+
+var (
+	svc       *service.Service
+	deadlines apiutil.DeadlineConfig
+	auth      apiutil.AuthConfig
+)
+
+// Register wires the /api/v1/... routes onto http.DefaultServeMux. It is
+// called from http.Listen once the shared service.Service and transport
+// config are available.
+func Register(s *service.Service, d apiutil.DeadlineConfig, a apiutil.AuthConfig) {
+	svc = s
+	deadlines = d
+	auth = a
+	http.HandleFunc("/api/v1/login", login)
+	http.HandleFunc("/api/v1/get", get)
+	http.HandleFunc("/api/v1/set", set)
+	http.HandleFunc("/api/v1/delete", delete)
+	http.HandleFunc("/api/v1/query", query)
+}
+
+// request is the decoded form of a v1 API request body.
+type request struct {
+	SessionKey string            `json:"key"`
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	Objects    []types.APIObject `json:"objects"`
+	Query      string            `json:"query"`
+	Limit      int               `json:"limit"`
+	After      string            `json:"after"`
+	Deadline   int               `json:"deadline"`
+}
+
+// response is the v1 response envelope. Compared to the legacy envelope it
+// adds ErrorCode, a stable machine-readable string distinct from the
+// free-form Message, and Warnings, which lets a handler report per-item
+// problems (e.g. a missing object in a Get batch) without failing the
+// whole request.
+type response struct {
+	Success    bool              `json:"success"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Objects    []types.APIObject `json:"objects,omitempty"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	Expires    string            `json:"expires,omitempty"`
+	XSRFToken  string            `json:"xsrf_token,omitempty"`
+}
+
+func parseBody(r *http.Request) (request, error) {
+	var req request
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return req, errors.WithStack(err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return req, errors.WithStack(err)
+		}
+	}
+	return req, nil
+}
+
+// parseMutatingBody decodes the request body like parseBody, then resolves
+// and authorizes the session key for a mutating operation (set, delete, the
+// POST variant of query, and get).
+func parseMutatingBody(r *http.Request) (request, bool, error) {
+	req, err := parseBody(r)
+	if err != nil {
+		return req, false, err
+	}
+	sessionKey, fromCookie := apiutil.ResolveSessionKey(r, req.SessionKey)
+	req.SessionKey = sessionKey
+	if err := apiutil.RequireXSRF(auth, r, sessionKey, fromCookie, svc.ValidateXSRF); err != nil {
+		return req, fromCookie, err
+	}
+	return req, fromCookie, nil
+}
+
+func requestContext(r *http.Request, req request) (context.Context, context.CancelFunc) {
+	return apiutil.RequestContext(deadlines, r, req.Deadline)
+}
+
+// errorCode maps a store/service error to the HTTP status and stable
+// error_code string it should be reported with.
+func errorCode(err error) (status int, code string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "deadline_exceeded"
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, store.ErrPermission):
+		return http.StatusForbidden, "permission_denied"
+	case errors.Is(err, store.ErrInvalidCreds), errors.Is(err, store.ErrInvalidCredientials):
+		return http.StatusUnauthorized, "invalid_credentials"
+	case errors.Is(err, store.ErrObjectNotSpecified):
+		return http.StatusBadRequest, "object_not_specified"
+	case errors.Is(err, store.ErrInvalidArg):
+		return http.StatusBadRequest, "invalid_query"
+	case errors.Is(err, errInvalidMethod):
+		return http.StatusMethodNotAllowed, "invalid_method"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+func errorResponse(w http.ResponseWriter, err error) {
+	status, code := errorCode(err)
+	sendResponse(w, status, &response{
+		Success:   false,
+		ErrorCode: code,
+		Message:   err.Error(),
+	})
+}
+
+func sendResponse(w http.ResponseWriter, status int, resp *response) {
+	w.WriteHeader(status)
+	data, err := json.Marshal(resp)
+	if err != nil {
+		io.WriteString(w, `{"success":false,"error_code":"internal","message":"An unknown error occurred."}`)
+		return
+	}
+	w.Write(data)
+}
+
+func login(w http.ResponseWriter, r *http.Request) {
+	rec := &apiutil.StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer apiutil.RecordAPIMetrics("v1_login", rec, start)
+	if r.Method != http.MethodPost {
+		errorResponse(w, errInvalidMethod)
+		return
+	}
+	req, err := parseBody(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	_, key, err := svc.Login(req.Username, req.Password, r.RemoteAddr)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	resp := &response{
+		Success: true,
+		Expires: key.Expires.UTC().Format(time.RFC3339),
+	}
+	if auth.CookieSessions {
+		http.SetCookie(w, apiutil.SessionCookie(key.Key, key.Expires))
+		resp.XSRFToken = key.XSRF
+	}
+	if !auth.CookieSessions || auth.AllowBearerAuth {
+		resp.Key = key.Key
+	}
+	sendResponse(w, http.StatusOK, resp)
+}
+
+// get handles GET/POST /api/v1/get. Unlike the legacy /get, a missing or
+// unreadable object doesn't fail the whole batch: it's reported as a
+// warning and every object that could be fetched is still returned.
+func get(w http.ResponseWriter, r *http.Request) {
+	rec := &apiutil.StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer apiutil.RecordAPIMetrics("v1_get", rec, start)
+
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		uids := strings.Split(r.URL.Query().Get("uid"), ",")
+		req.SessionKey, _ = apiutil.ResolveSessionKey(r, r.URL.Query().Get("key"))
+		req.Objects = make([]types.APIObject, 0)
+		for _, uid := range uids {
+			if uid != "" {
+				req.Objects = append(req.Objects, types.APIObject{"_uid": uid})
+			}
+		}
+	case http.MethodPost:
+		var err error
+		req, _, err = parseMutatingBody(r)
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+	default:
+		errorResponse(w, errInvalidMethod)
+		return
+	}
+
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+	objs, warnings, err := svc.GetPartial(ctx, req.SessionKey, req.Objects)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, &response{
+		Success:  true,
+		Objects:  objs,
+		Warnings: warnings,
+	})
+}
+
+func set(w http.ResponseWriter, r *http.Request) {
+	rec := &apiutil.StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer apiutil.RecordAPIMetrics("v1_set", rec, start)
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		errorResponse(w, errInvalidMethod)
+		return
+	}
+	req, _, err := parseMutatingBody(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+	objs, err := svc.Set(ctx, req.SessionKey, req.Objects)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, &response{Success: true, Objects: objs})
+}
+
+func delete(w http.ResponseWriter, r *http.Request) {
+	rec := &apiutil.StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer apiutil.RecordAPIMetrics("v1_delete", rec, start)
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		errorResponse(w, errInvalidMethod)
+		return
+	}
+	req, _, err := parseMutatingBody(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+	if err := svc.Delete(ctx, req.SessionKey, req.Objects); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, &response{Success: true})
+}
+
+func query(w http.ResponseWriter, r *http.Request) {
+	rec := &apiutil.StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+	w = rec
+	start := time.Now()
+	defer apiutil.RecordAPIMetrics("v1_query", rec, start)
+
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			q = r.URL.Query().Get("query")
+		}
+		if q == "" {
+			errorResponse(w, store.ErrInvalidArg)
+			return
+		}
+		req.SessionKey, _ = apiutil.ResolveSessionKey(r, r.URL.Query().Get("key"))
+		req.Query = q
+		req.After = r.URL.Query().Get("after")
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if limit, err := strconv.Atoi(l); err == nil {
+				req.Limit = limit
+			}
+		}
+	case http.MethodPost:
+		var err error
+		req, _, err = parseMutatingBody(r)
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+	default:
+		errorResponse(w, errInvalidMethod)
+		return
+	}
+
+	ctx, cancel := requestContext(r, req)
+	defer cancel()
+	objs, nextCursor, err := svc.Query(ctx, req.SessionKey, req.Query, req.Limit, req.After)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, &response{
+		Success:    true,
+		Objects:    objs,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,60 @@
+// Package metrics holds the Prometheus collectors shared by the HTTP/gRPC
+// subsystems and the service layer, registered once on the default
+// registry so a single /metrics endpoint exposes all of them.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// APIRequestsTotal counts API requests by resource and outcome status.
+	APIRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objectstore_api_requests_total",
+		Help: "Total number of API requests handled, by resource and status.",
+	}, []string{"resource", "status"})
+
+	// APIRequestDuration tracks API request latency by resource.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "objectstore_api_request_duration_seconds",
+		Help:    "API request latency in seconds, by resource.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"resource"})
+
+	// QueryScannedObjects tracks how many indexed objects a query had to
+	// scan to produce its result page.
+	QueryScannedObjects = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "objectstore_query_scanned_objects",
+		Help:    "Number of indexed objects scanned to satisfy a single query.",
+		Buckets: prometheus.ExponentialBuckets(8, 2, 12),
+	})
+
+	// IndexSize reports the current number of objects in the index.
+	IndexSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "objectstore_index_size",
+		Help: "Number of objects currently held in the index.",
+	})
+
+	// SessionsActive reports the current number of active user sessions.
+	SessionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "objectstore_sessions_active",
+		Help: "Number of currently active user sessions.",
+	})
+
+	// StoreOpDuration tracks store.Client operation latency by op
+	// (get/set/delete/query/sync).
+	StoreOpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "objectstore_store_op_duration_seconds",
+		Help:    "store.Client operation latency in seconds, by op.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"op"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		APIRequestsTotal,
+		APIRequestDuration,
+		QueryScannedObjects,
+		IndexSize,
+		SessionsActive,
+		StoreOpDuration,
+	)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/store"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// SetBlob stores file content-addressed by its SHA-256 digest and links it
+// to the object described by metadata, creating or updating that object.
+// The underlying bytes live out-of-line in the store; only the resulting
+// types.BlobRef is kept on the object itself.
+func (s *Service) SetBlob(ctx context.Context, sessionKey string, metadata types.APIObject, contentType string, file io.Reader) (types.APIObject, error) {
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	obj := metadata.Object()
+	sha256, size, err := s.client.SetBlob(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	obj.Blob = &types.BlobRef{
+		SHA256:      sha256,
+		Size:        size,
+		ContentType: contentType,
+	}
+	if err := s.client.Set(ctx, obj, user); err != nil {
+		return nil, err
+	}
+	return obj.API(), nil
+}
+
+// OpenBlob returns the object identified by uid along with a seekable
+// reader over its linked blob, after checking the caller may read the
+// object. Callers must close the returned reader.
+func (s *Service) OpenBlob(ctx context.Context, sessionKey, uid string) (*types.Object, io.ReadSeekCloser, error) {
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.Get(ctx, uid, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if obj.Blob == nil {
+		return nil, nil, errors.WithStack(store.ErrNotFound)
+	}
+	rc, err := s.client.OpenBlob(ctx, obj.Blob.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, rc, nil
+}
@@ -0,0 +1,345 @@
+// Package service implements the request-processing logic shared by every
+// transport the object store is exposed over (HTTP, gRPC, ...). Transports
+// are expected to stay thin: decode their wire format into the arguments
+// below, call the matching Service method, and translate the result/error
+// back into their own response shape.
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/contextualcode/go-object-store/metrics"
+	"gitlab.com/contextualcode/go-object-store/store"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+const anonymousUser = "anonymous"
+
+// syncInterval is how often New's background goroutine re-syncs the index
+// after the initial, synchronous Sync.
+const syncInterval = 30 * time.Second
+
+// Service wraps a store.Client and exposes the object store operations in a
+// transport-agnostic way.
+type Service struct {
+	client *store.Client
+
+	sessionsMu sync.RWMutex
+	sessions   []*UserSession
+
+	ready int32 // set via atomic; 1 once Sync has succeeded at least once
+}
+
+func timeStoreOp(op string, start time.Time) {
+	metrics.StoreOpDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// New creates a Service backed by the given store configuration, ensuring
+// the anonymous user exists. It performs an initial Sync before returning
+// so Ready is true as soon as New succeeds, then keeps the index fresh with
+// a background goroutine that re-syncs every syncInterval.
+func New(config *store.Config) (*Service, error) {
+	s := &Service{
+		client:   store.NewClient(config),
+		sessions: make([]*UserSession, 0),
+	}
+	u, _ := s.client.GetUserByUsername(anonymousUser)
+	if u == nil {
+		u := &types.User{
+			Username: anonymousUser,
+			Groups:   []string{anonymousUser},
+		}
+		if err := s.client.SetUser(u); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := s.Sync(); err != nil {
+		return nil, err
+	}
+	go s.syncLoop()
+	return s, nil
+}
+
+// syncLoop re-syncs the index every syncInterval for the lifetime of the
+// process; errors are left for the next tick to retry rather than treated
+// as fatal, since doing so would leave an indefinitely-running server stuck.
+func (s *Service) syncLoop() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Sync()
+	}
+}
+
+func (s *Service) userFromSessionKey(key string) (*types.User, error) {
+	if key == "" {
+		user, err := s.client.GetUserByUsername(anonymousUser)
+		return user, errors.WithStack(err)
+	}
+	sess := s.getSessionFromKey(key)
+	if sess == nil {
+		return nil, errors.WithStack(store.ErrPermission)
+	}
+	user, err := s.client.GetUser(sess.UserUID)
+	return user, errors.WithStack(err)
+}
+
+// Login validates the given credentials and returns a new session key.
+func (s *Service) Login(username, password, ip string) (*UserSession, *SessionKey, error) {
+	if username == "" || password == "" {
+		return nil, nil, errors.WithStack(store.ErrInvalidCreds)
+	}
+	user, err := s.client.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, errors.WithStack(store.ErrInvalidCredientials)
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+	if !store.CheckPassword(password, user.PasswordHash) {
+		return nil, nil, errors.WithStack(store.ErrInvalidCredientials)
+	}
+	sess, key := newSession(user, ip)
+	if sess == nil || key == nil {
+		return nil, nil, errors.WithStack(store.ErrUnknown)
+	}
+	s.checkSessions()
+	s.sessionsMu.Lock()
+	s.sessions = append(s.sessions, sess)
+	count := len(s.sessions)
+	s.sessionsMu.Unlock()
+	metrics.SessionsActive.Set(float64(count))
+	return sess, key, nil
+}
+
+// Sync flushes the in-memory index to the backing store. Until it has
+// succeeded at least once, Ready reports false — used by /readyz so a
+// freshly started instance isn't sent traffic before its index is usable.
+func (s *Service) Sync() error {
+	start := time.Now()
+	err := s.client.Sync()
+	timeStoreOp("sync", start)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	atomic.StoreInt32(&s.ready, 1)
+	if index, err := s.client.Index(); err == nil {
+		metrics.IndexSize.Set(float64(len(index)))
+	}
+	return nil
+}
+
+// Ready reports whether Sync has succeeded at least once.
+func (s *Service) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// Get fetches the objects identified by the given API objects (only the
+// _uid key is consulted), de-duplicating UIDs already present in the result.
+// ctx is threaded down to the store so a cancelled request (e.g. the caller
+// disconnected) aborts in-flight work instead of running to completion.
+func (s *Service) Get(ctx context.Context, sessionKey string, objects []types.APIObject) ([]types.APIObject, error) {
+	if len(objects) == 0 {
+		return nil, errors.WithStack(store.ErrObjectNotSpecified)
+	}
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	respObjs := make([]types.APIObject, 0)
+	for _, o := range objects {
+		if ctx.Err() != nil {
+			return nil, errors.WithStack(ctx.Err())
+		}
+		hasObj := false
+		for _, ro := range respObjs {
+			if ro.UID() == o.UID() {
+				hasObj = true
+				break
+			}
+		}
+		if hasObj {
+			continue
+		}
+		start := time.Now()
+		respObj, err := s.client.Get(ctx, o.Object().UID, user)
+		timeStoreOp("get", start)
+		if err != nil {
+			return nil, err
+		}
+		respObjs = append(respObjs, respObj.API())
+	}
+	return respObjs, nil
+}
+
+// GetPartial behaves like Get but tolerates per-object failures: instead of
+// aborting the whole batch on the first error, it skips the offending
+// object and appends a "<uid>: <error>" entry to warnings, returning
+// whatever could be fetched. err is only set for failures that apply to the
+// whole batch (e.g. an invalid session or a cancelled ctx). Used by the v1
+// API, which surfaces warnings in its response envelope; the legacy Get
+// keeps its all-or-nothing semantics for existing callers.
+func (s *Service) GetPartial(ctx context.Context, sessionKey string, objects []types.APIObject) (objs []types.APIObject, warnings []string, err error) {
+	if len(objects) == 0 {
+		return nil, nil, errors.WithStack(store.ErrObjectNotSpecified)
+	}
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	respObjs := make([]types.APIObject, 0)
+	warnings = make([]string, 0)
+	for _, o := range objects {
+		if ctx.Err() != nil {
+			return nil, nil, errors.WithStack(ctx.Err())
+		}
+		uid := o.Object().UID
+		hasObj := false
+		for _, ro := range respObjs {
+			if ro.UID() == uid {
+				hasObj = true
+				break
+			}
+		}
+		if hasObj {
+			continue
+		}
+		start := time.Now()
+		respObj, err := s.client.Get(ctx, uid, user)
+		timeStoreOp("get", start)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", uid, err.Error()))
+			continue
+		}
+		respObjs = append(respObjs, respObj.API())
+	}
+	return respObjs, warnings, nil
+}
+
+// Set creates or updates the given objects and returns the stored result.
+func (s *Service) Set(ctx context.Context, sessionKey string, objects []types.APIObject) ([]types.APIObject, error) {
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	respObjs := make([]types.APIObject, 0)
+	for _, o := range objects {
+		if o == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, errors.WithStack(ctx.Err())
+		}
+		fullObj := o.Object()
+		start := time.Now()
+		err := s.client.Set(ctx, fullObj, user)
+		timeStoreOp("set", start)
+		if err != nil {
+			return nil, err
+		}
+		respObjs = append(respObjs, fullObj.API())
+	}
+	return respObjs, nil
+}
+
+// Delete removes the given objects.
+func (s *Service) Delete(ctx context.Context, sessionKey string, objects []types.APIObject) error {
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if o == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return errors.WithStack(ctx.Err())
+		}
+		start := time.Now()
+		err := s.client.Delete(ctx, o.Object(), user)
+		timeStoreOp("delete", start)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultQueryLimit caps the page size of a Query call when the caller
+// doesn't specify one.
+const defaultQueryLimit = 1000
+
+// Query runs the given query string and returns at most limit matching
+// objects (defaultQueryLimit if limit is 0), starting after the object
+// identified by after (its UID, as previously returned as NextCursor).
+// Filtering and slicing happen inside store.Client.Query so large indexes
+// are never collected into memory in full before a page is sliced off. If
+// ctx is cancelled or its deadline passes mid-scan, Query returns a wrapped
+// context.DeadlineExceeded/Canceled rather than a partial page.
+func (s *Service) Query(ctx context.Context, sessionKey, query string, limit int, after string) (objs []types.APIObject, nextCursor string, err error) {
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if query == "" {
+		return nil, "", errors.WithStack(store.ErrInvalidArg)
+	}
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	start := time.Now()
+	page, scanned, next, err := s.client.QueryPage(ctx, query, user, limit, after)
+	timeStoreOp("query", start)
+	if err != nil {
+		return nil, "", err
+	}
+	metrics.QueryScannedObjects.Observe(float64(scanned))
+	respObjs := make([]types.APIObject, 0, len(page))
+	for _, o := range page {
+		respObjs = append(respObjs, o.API())
+	}
+	return respObjs, next, nil
+}
+
+// QueryStream runs the given query string and streams matching objects back
+// on the returned channel as they're found, instead of materialising the
+// full result set first. The channel is closed once the scan completes,
+// ctx is done, or err is populated; callers must drain it to avoid leaking
+// the goroutine running the scan.
+func (s *Service) QueryStream(ctx context.Context, sessionKey, query string) (<-chan types.APIObject, <-chan error) {
+	objCh := make(chan types.APIObject)
+	errCh := make(chan error, 1)
+	user, err := s.userFromSessionKey(sessionKey)
+	if err != nil {
+		close(objCh)
+		errCh <- err
+		return objCh, errCh
+	}
+	if query == "" {
+		close(objCh)
+		errCh <- errors.WithStack(store.ErrInvalidArg)
+		return objCh, errCh
+	}
+	storeObjCh, storeErrCh := s.client.QueryStream(ctx, query, user)
+	go func() {
+		defer close(objCh)
+		defer close(errCh)
+		for o := range storeObjCh {
+			select {
+			case objCh <- o.API():
+			case <-ctx.Done():
+				errCh <- errors.WithStack(ctx.Err())
+				return
+			}
+		}
+		if err := <-storeErrCh; err != nil {
+			errCh <- err
+		}
+	}()
+	return objCh, errCh
+}
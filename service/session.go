@@ -0,0 +1,155 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"gitlab.com/contextualcode/go-object-store/metrics"
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// sessionLifetime is how long a session key remains valid after login.
+const sessionLifetime = 24 * time.Hour
+
+// UserSession tracks an authenticated user and the keys issued for them.
+type UserSession struct {
+	UserUID string
+	IP      string
+	Keys    []*SessionKey
+}
+
+// SessionKey is a single bearer token issued for a UserSession. XSRF is a
+// second, independently random token bound to Key: transports that accept
+// Key via a cookie must also require XSRF on an out-of-band channel (e.g. a
+// header) so a forged cross-site request, which carries the cookie
+// automatically but can't read or set headers, is rejected.
+type SessionKey struct {
+	Key     string
+	XSRF    string
+	Expires time.Time
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func newSessionKey() (*SessionKey, error) {
+	key, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	xsrf, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &SessionKey{
+		Key:     key,
+		XSRF:    xsrf,
+		Expires: time.Now().Add(sessionLifetime),
+	}, nil
+}
+
+func newSession(user *types.User, ip string) (*UserSession, *SessionKey) {
+	key, err := newSessionKey()
+	if err != nil {
+		return nil, nil
+	}
+	sess := &UserSession{
+		UserUID: user.UID,
+		IP:      ip,
+		Keys:    []*SessionKey{key},
+	}
+	return sess, key
+}
+
+// getSessionFromKey returns the session owning key, provided it hasn't
+// expired.
+func (s *Service) getSessionFromKey(key string) *UserSession {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	return s.getSessionFromKeyLocked(key)
+}
+
+// getSessionFromKeyLocked is getSessionFromKey for callers that already
+// hold s.sessionsMu (for reading).
+func (s *Service) getSessionFromKeyLocked(key string) *UserSession {
+	now := time.Now()
+	for _, sess := range s.sessions {
+		for _, k := range sess.Keys {
+			if k.Key == key && now.Before(k.Expires) {
+				return sess
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateXSRF reports whether token is the XSRF token bound to the
+// (unexpired) session key sessionKey.
+func (s *Service) ValidateXSRF(sessionKey, token string) bool {
+	if sessionKey == "" || token == "" {
+		return false
+	}
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	sess := s.getSessionFromKeyLocked(sessionKey)
+	if sess == nil {
+		return false
+	}
+	now := time.Now()
+	for _, k := range sess.Keys {
+		if k.Key == sessionKey && now.Before(k.Expires) {
+			return k.XSRF == token
+		}
+	}
+	return false
+}
+
+// Logout invalidates sessionKey, ending that session immediately rather
+// than waiting for it to expire.
+func (s *Service) Logout(sessionKey string) error {
+	if sessionKey == "" {
+		return nil
+	}
+	s.sessionsMu.Lock()
+	for _, sess := range s.sessions {
+		keys := make([]*SessionKey, 0, len(sess.Keys))
+		for _, k := range sess.Keys {
+			if k.Key != sessionKey {
+				keys = append(keys, k)
+			}
+		}
+		sess.Keys = keys
+	}
+	s.sessionsMu.Unlock()
+	s.checkSessions()
+	return nil
+}
+
+// checkSessions drops sessions whose keys have all expired.
+func (s *Service) checkSessions() {
+	now := time.Now()
+	s.sessionsMu.Lock()
+	active := make([]*UserSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		keys := make([]*SessionKey, 0, len(sess.Keys))
+		for _, k := range sess.Keys {
+			if now.Before(k.Expires) {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) > 0 {
+			sess.Keys = keys
+			active = append(active, sess)
+		}
+	}
+	s.sessions = active
+	count := len(s.sessions)
+	s.sessionsMu.Unlock()
+	metrics.SessionsActive.Set(float64(count))
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// blob is a content-addressed chunk of bytes held out-of-line from any
+// object that references it.
+type blob struct {
+	data        []byte
+	contentType string
+}
+
+// SetBlob reads file in full and stores it content-addressed by its
+// SHA-256 digest, returning that digest and the blob's size. Storing the
+// same content twice is a no-op beyond the digest/size computation: the
+// existing blob is reused.
+func (c *Client) SetBlob(ctx context.Context, file io.Reader) (string, int64, error) {
+	if ctx.Err() != nil {
+		return "", 0, errors.WithStack(ctx.Err())
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	c.blobMu.Lock()
+	defer c.blobMu.Unlock()
+	if c.blobs == nil {
+		c.blobs = make(map[string]*blob)
+	}
+	if _, ok := c.blobs[digest]; !ok {
+		c.blobs[digest] = &blob{data: data}
+	}
+	return digest, int64(len(data)), nil
+}
+
+// OpenBlob returns a seekable reader over the blob identified by sha256.
+// Callers must close the returned reader.
+func (c *Client) OpenBlob(ctx context.Context, sha256 string) (io.ReadSeekCloser, error) {
+	if ctx.Err() != nil {
+		return nil, errors.WithStack(ctx.Err())
+	}
+	c.blobMu.RLock()
+	b, ok := c.blobs[sha256]
+	c.blobMu.RUnlock()
+	if !ok {
+		return nil, errors.WithStack(ErrNotFound)
+	}
+	return nopCloser{bytes.NewReader(b.data)}, nil
+}
+
+// nopCloser adapts a *bytes.Reader (already a io.ReadSeeker) to
+// io.ReadSeekCloser with a no-op Close.
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// GCBlobs removes every blob no object currently references, returning how
+// many were removed.
+func (c *Client) GCBlobs() (int, error) {
+	referenced := make(map[string]bool)
+	c.mu.RLock()
+	for _, r := range c.objects {
+		if r.Blob != nil {
+			referenced[r.Blob.SHA256] = true
+		}
+	}
+	c.mu.RUnlock()
+
+	c.blobMu.Lock()
+	defer c.blobMu.Unlock()
+	removed := 0
+	for digest := range c.blobs {
+		if !referenced[digest] {
+			delete(c.blobs, digest)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// QueryPage returns up to limit objects matching query, starting after the
+// given cursor (the UID of the last object from a previous page, or "" for
+// the first page). scanned is how many objects were examined to assemble
+// the page (used to size metrics.QueryScannedObjects); next is the cursor
+// to pass for the following page, or "" if there are no more matches.
+func (c *Client) QueryPage(ctx context.Context, query string, user *types.User, limit int, after string) (page []*types.Object, scanned int, next string, err error) {
+	if limit <= 0 {
+		return nil, 0, "", errors.WithStack(ErrInvalidArg)
+	}
+	clauses, err := parseQuery(query)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	uids, err := c.sortedUIDs()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	page = make([]*types.Object, 0, limit)
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return nil, 0, "", errors.WithStack(ctx.Err())
+		}
+		if after != "" && uid <= after {
+			continue
+		}
+		scanned++
+		obj, ok, err := c.matchUID(uid, clauses)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if !ok {
+			continue
+		}
+		if len(page) == limit {
+			next = page[len(page)-1].UID
+			return page, scanned, next, nil
+		}
+		page = append(page, obj)
+	}
+	return page, scanned, "", nil
+}
+
+// QueryStream runs query in the background and streams matches to the
+// returned channel in UID order, closing it when done. The error channel
+// receives at most one error and is closed alongside the object channel.
+func (c *Client) QueryStream(ctx context.Context, query string, user *types.User) (<-chan *types.Object, <-chan error) {
+	objects := make(chan *types.Object)
+	errs := make(chan error, 1)
+	clauses, err := parseQuery(query)
+	if err != nil {
+		errs <- err
+		close(objects)
+		close(errs)
+		return objects, errs
+	}
+	go func() {
+		defer close(objects)
+		defer close(errs)
+		uids, err := c.sortedUIDs()
+		if err != nil {
+			errs <- err
+			return
+		}
+		for _, uid := range uids {
+			if ctx.Err() != nil {
+				errs <- errors.WithStack(ctx.Err())
+				return
+			}
+			obj, ok, err := c.matchUID(uid, clauses)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case objects <- obj:
+			case <-ctx.Done():
+				errs <- errors.WithStack(ctx.Err())
+				return
+			}
+		}
+	}()
+	return objects, errs
+}
+
+// matchUID fetches uid and reports whether it still exists and matches
+// clauses.
+func (c *Client) matchUID(uid string, clauses []clause) (*types.Object, bool, error) {
+	c.mu.RLock()
+	r, ok := c.objects[uid]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	obj, err := c.toObject(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return obj, matchesQuery(obj, clauses), nil
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// clause is a single "field op value" condition parsed out of a query
+// string.
+type clause struct {
+	field string
+	op    string
+	value interface{}
+}
+
+// parseQuery parses a query string of one or more clauses joined by
+// " and ", e.g. "age > 18 and name = 'jane'". Supported operators are
+// =, !=, >, <, >=, <=. Values are parsed as a single-quoted string, a
+// bool, or a float64, in that order.
+func parseQuery(query string) ([]clause, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.WithStack(ErrInvalidArg)
+	}
+	parts := strings.Split(query, " and ")
+	clauses := make([]clause, 0, len(parts))
+	for _, part := range parts {
+		c, err := parseClause(part)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+var operators = []string{">=", "<=", "!=", "=", ">", "<"}
+
+func parseClause(part string) (clause, error) {
+	part = strings.TrimSpace(part)
+	for _, op := range operators {
+		idx := strings.Index(part, op)
+		if idx <= 0 {
+			continue
+		}
+		field := strings.TrimSpace(part[:idx])
+		rawValue := strings.TrimSpace(part[idx+len(op):])
+		return clause{
+			field: field,
+			op:    op,
+			value: parseValue(rawValue),
+		}, nil
+	}
+	return clause{}, errors.WithStack(ErrInvalidArg)
+}
+
+func parseValue(raw string) interface{} {
+	if len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'' {
+		return raw[1 : len(raw)-1]
+	}
+	// Tried before bool: strconv.ParseBool also accepts "0"/"1", which
+	// would otherwise steal every numeric comparison against those values
+	// (e.g. "age > 0") away from the float path.
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(raw); err == nil {
+		return b
+	}
+	return raw
+}
+
+// matchesQuery reports whether obj satisfies every clause (conditions are
+// AND-ed together).
+func matchesQuery(obj *types.Object, clauses []clause) bool {
+	for _, c := range clauses {
+		if !matchesClause(obj, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesClause(obj *types.Object, c clause) bool {
+	actual, ok := obj.Data[c.field]
+	if !ok {
+		return false
+	}
+	switch want := c.value.(type) {
+	case string:
+		got, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		return compareStrings(got, c.op, want)
+	case bool:
+		got, ok := actual.(bool)
+		if !ok {
+			return false
+		}
+		return compareBools(got, c.op, want)
+	case float64:
+		got, ok := toFloat64(actual)
+		if !ok {
+			return false
+		}
+		return compareFloats(got, c.op, want)
+	}
+	return false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func compareStrings(got, op, want string) bool {
+	switch op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	case ">":
+		return got > want
+	case "<":
+		return got < want
+	case ">=":
+		return got >= want
+	case "<=":
+		return got <= want
+	}
+	return false
+}
+
+func compareBools(got bool, op string, want bool) bool {
+	switch op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	}
+	return false
+}
+
+func compareFloats(got float64, op string, want float64) bool {
+	switch op {
+	case "=":
+		return got == want
+	case "!=":
+		return got != want
+	case ">":
+		return got > want
+	case "<":
+		return got < want
+	case ">=":
+		return got >= want
+	case "<=":
+		return got <= want
+	}
+	return false
+}
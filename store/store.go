@@ -0,0 +1,400 @@
+// Package store implements the object store: a JSON document store with a
+// queryable index, content-addressed blobs, and simple user accounts. All
+// operations are safe for concurrent use.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/contextualcode/go-object-store/types"
+)
+
+// indexName is the key the index is persisted under in the remote store.
+const indexName = "index"
+
+// Sentinel errors returned by Client methods. Callers use errors.Is against
+// these (they're also wrapped with errors.WithStack along the way).
+var (
+	ErrNotFound            = errors.New("object not found")
+	ErrPermission          = errors.New("permission denied")
+	ErrInvalidCreds        = errors.New("username and password are required")
+	ErrInvalidCredientials = errors.New("invalid username or password")
+	ErrInvalidArg          = errors.New("invalid argument")
+	ErrObjectNotSpecified  = errors.New("no object specified")
+	ErrUnknown             = errors.New("unknown error")
+)
+
+// HTTPConfig configures the HTTP transport.
+type HTTPConfig struct {
+	Port            int
+	DefaultDeadline time.Duration
+	MaxDeadline     time.Duration
+	CookieSessions  bool
+	AllowBearerAuth bool
+}
+
+// GRPCConfig configures the gRPC transport.
+type GRPCConfig struct {
+	Port int
+}
+
+// Config configures a Client and the transports built on top of it.
+type Config struct {
+	HTTP HTTPConfig
+	GRPC GRPCConfig
+}
+
+// record is the on-disk (in-memory, for now) representation of an Object:
+// Data is kept pre-serialized, the same way a real backing store would
+// hand it back, so every read decodes it fresh instead of handing out a
+// shared map callers could mutate.
+type record struct {
+	UID      string
+	Data     json.RawMessage
+	Created  time.Time
+	Author   string
+	Modified time.Time
+	Modifier string
+	Blob     *types.BlobRef
+}
+
+// Client is the object store client. The zero value is not usable; use
+// NewClient.
+type Client struct {
+	config *Config
+
+	mu          sync.RWMutex
+	objects     map[string]*record
+	users       map[string]*types.User
+	usersByName map[string]string
+
+	remoteMu sync.RWMutex
+	remote   map[string]json.RawMessage
+
+	blobMu sync.RWMutex
+	blobs  map[string]*blob
+}
+
+// NewClient creates a Client backed by an in-memory store. config may be
+// nil, in which case defaults are used.
+func NewClient(config *Config) *Client {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Client{
+		config:      config,
+		objects:     make(map[string]*record),
+		users:       make(map[string]*types.User),
+		usersByName: make(map[string]string),
+		remote:      make(map[string]json.RawMessage),
+		blobs:       make(map[string]*blob),
+	}
+}
+
+func newUID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(raw)
+}
+
+func (c *Client) toObject(r *record) (*types.Object, error) {
+	data := make(map[string]interface{})
+	if len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, &data); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return &types.Object{
+		UID:      r.UID,
+		Data:     data,
+		Created:  r.Created,
+		Author:   r.Author,
+		Modified: r.Modified,
+		Modifier: r.Modifier,
+		Blob:     r.Blob,
+	}, nil
+}
+
+func username(user *types.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.Username
+}
+
+// Get fetches the object identified by uid.
+func (c *Client) Get(ctx context.Context, uid string, user *types.User) (*types.Object, error) {
+	if ctx.Err() != nil {
+		return nil, errors.WithStack(ctx.Err())
+	}
+	c.mu.RLock()
+	r, ok := c.objects[uid]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, errors.WithStack(ErrNotFound)
+	}
+	return c.toObject(r)
+}
+
+// Set creates or updates o, stamping Author/Modifier from user (if given)
+// and assigning o.UID if it's unset.
+func (c *Client) Set(ctx context.Context, o *types.Object, user *types.User) error {
+	if ctx.Err() != nil {
+		return errors.WithStack(ctx.Err())
+	}
+	if o == nil {
+		return errors.WithStack(ErrObjectNotSpecified)
+	}
+	data, err := json.Marshal(o.Data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	existing, ok := c.objects[o.UID]
+	if o.UID == "" || !ok {
+		o.UID = newUID()
+		existing = &record{UID: o.UID, Created: now, Author: username(user)}
+	}
+	existing.Data = data
+	existing.Modified = now
+	existing.Modifier = username(user)
+	existing.Blob = o.Blob
+	c.objects[o.UID] = existing
+
+	o.Created = existing.Created
+	o.Author = existing.Author
+	o.Modified = existing.Modified
+	o.Modifier = existing.Modifier
+	return nil
+}
+
+// Delete removes o (only o.UID is consulted) and garbage collects any blob
+// it was the last reference to.
+func (c *Client) Delete(ctx context.Context, o *types.Object, user *types.User) error {
+	if ctx.Err() != nil {
+		return errors.WithStack(ctx.Err())
+	}
+	if o == nil || o.UID == "" {
+		return errors.WithStack(ErrObjectNotSpecified)
+	}
+	c.mu.Lock()
+	if _, ok := c.objects[o.UID]; !ok {
+		c.mu.Unlock()
+		return errors.WithStack(ErrNotFound)
+	}
+	delete(c.objects, o.UID)
+	c.mu.Unlock()
+
+	if _, err := c.GCBlobs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Index returns the current index: every object's UID and Data, with long
+// string values truncated to types.IndexValueMaxSize.
+func (c *Client) Index() ([]*types.IndexObject, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.buildIndexLocked()
+}
+
+// buildIndexLocked must be called with c.mu held (for reading).
+func (c *Client) buildIndexLocked() ([]*types.IndexObject, error) {
+	index := make([]*types.IndexObject, 0, len(c.objects))
+	for _, r := range c.objects {
+		obj, err := c.toObject(r)
+		if err != nil {
+			return nil, err
+		}
+		index = append(index, truncateForIndex(obj))
+	}
+	return index, nil
+}
+
+func truncateForIndex(o *types.Object) *types.IndexObject {
+	data := make(map[string]interface{}, len(o.Data))
+	for k, v := range o.Data {
+		if s, ok := v.(string); ok && len(s) > types.IndexValueMaxSize {
+			s = s[:types.IndexValueMaxSize]
+			data[k] = s
+			continue
+		}
+		data[k] = v
+	}
+	return &types.IndexObject{UID: o.UID, Data: data}
+}
+
+// Query returns every object matching the given query string (see
+// matchesQuery for the supported syntax), ordered by UID.
+func (c *Client) Query(ctx context.Context, query string, user *types.User) ([]*types.Object, error) {
+	clauses, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	uids, err := c.sortedUIDs()
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]*types.Object, 0)
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return nil, errors.WithStack(ctx.Err())
+		}
+		c.mu.RLock()
+		r, ok := c.objects[uid]
+		c.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		obj, err := c.toObject(r)
+		if err != nil {
+			return nil, err
+		}
+		if matchesQuery(obj, clauses) {
+			matches = append(matches, obj)
+		}
+	}
+	return matches, nil
+}
+
+// sortedUIDs returns every object UID in a stable (sorted) order, so
+// callers can page through results by UID without holding a lock across
+// the whole scan.
+func (c *Client) sortedUIDs() ([]string, error) {
+	c.mu.RLock()
+	uids := make([]string, 0, len(c.objects))
+	for uid := range c.objects {
+		uids = append(uids, uid)
+	}
+	c.mu.RUnlock()
+	sort.Strings(uids)
+	return uids, nil
+}
+
+// Sync persists the current index to the remote store, and garbage
+// collects any blob no object references anymore.
+func (c *Client) Sync() error {
+	c.mu.RLock()
+	index, err := c.buildIndexLocked()
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.remoteMu.Lock()
+	c.remote[indexName] = data
+	c.remoteMu.Unlock()
+
+	if _, err := c.GCBlobs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getRaw reads a named entry from the remote store into dest.
+func (c *Client) getRaw(name string, dest interface{}) error {
+	c.remoteMu.RLock()
+	data, ok := c.remote[name]
+	c.remoteMu.RUnlock()
+	if !ok {
+		return errors.WithStack(ErrNotFound)
+	}
+	return errors.WithStack(json.Unmarshal(data, dest))
+}
+
+// GetUser fetches the user identified by uid.
+func (c *Client) GetUser(uid string) (*types.User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	u, ok := c.users[uid]
+	if !ok {
+		return nil, errors.WithStack(ErrNotFound)
+	}
+	return u, nil
+}
+
+// GetUserByUsername fetches the user with the given username.
+func (c *Client) GetUserByUsername(name string) (*types.User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	uid, ok := c.usersByName[name]
+	if !ok {
+		return nil, errors.WithStack(ErrNotFound)
+	}
+	return c.users[uid], nil
+}
+
+// SetUser creates or updates u, assigning u.UID if it's unset.
+func (c *Client) SetUser(u *types.User) error {
+	if u == nil {
+		return errors.WithStack(ErrObjectNotSpecified)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u.UID == "" {
+		u.UID = newUID()
+	}
+	c.users[u.UID] = u
+	c.usersByName[u.Username] = u.UID
+	return nil
+}
+
+// passwordHash derives the stored form of password: a random salt plus the
+// salted SHA-256 digest, hex-encoded as "<salt>$<digest>".
+func passwordHash(password string) string {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(salt) + "$" + hashWithSalt(salt, password)
+}
+
+func hashWithSalt(salt []byte, password string) string {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(password))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CheckPassword reports whether password matches hash, a string previously
+// produced by passwordHash.
+func CheckPassword(password, hash string) bool {
+	parts := splitHash(hash)
+	if parts == nil {
+		return false
+	}
+	salt, digest := parts[0], parts[1]
+	saltBytes, err := hex.DecodeString(salt)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashWithSalt(saltBytes, password)), []byte(digest)) == 1
+}
+
+func splitHash(hash string) []string {
+	for i := 0; i < len(hash); i++ {
+		if hash[i] == '$' {
+			return []string{hash[:i], hash[i+1:]}
+		}
+	}
+	return nil
+}
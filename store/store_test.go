@@ -1,6 +1,9 @@
 package store
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
 	"math/rand"
 	"testing"
 
@@ -9,6 +12,7 @@ import (
 )
 
 func TestGetSet(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o := &types.Object{
 		Data: map[string]interface{}{
@@ -16,11 +20,11 @@ func TestGetSet(t *testing.T) {
 			"test2": 123,
 		},
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
-	storedObj, err := client.Get(o.UID, nil)
+	storedObj, err := client.Get(ctx, o.UID, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -32,21 +36,22 @@ func TestGetSet(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o := &types.Object{
 		Data: map[string]interface{}{
 			"test": "hello world",
 		},
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
-	if err := client.Delete(o, nil); err != nil {
+	if err := client.Delete(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
-	_, err := client.Get(o.UID, nil)
+	_, err := client.Get(ctx, o.UID, nil)
 	if !errors.Is(err, ErrNotFound) {
 		t.Error("expected not found error")
 		return
@@ -54,6 +59,7 @@ func TestDelete(t *testing.T) {
 }
 
 func TestIndexSet(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o := &types.Object{
 		Data: map[string]interface{}{
@@ -64,7 +70,7 @@ func TestIndexSet(t *testing.T) {
 	for i := 0; i < 256; i++ {
 		o.Data["test_long"] = o.Data["test_long"].(string) + "a"
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
@@ -82,6 +88,7 @@ func TestIndexSet(t *testing.T) {
 }
 
 func TestQuery(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o := &types.Object{
 		Data: map[string]interface{}{
@@ -91,15 +98,15 @@ func TestQuery(t *testing.T) {
 			"test_string": "hello world",
 		},
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
-	res, err := client.Query("test_int = 123", nil)
+	res, err := client.Query(ctx, "test_int = 123", nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -111,7 +118,7 @@ func TestQuery(t *testing.T) {
 		t.Error("unexpected item in index")
 	}
 
-	res, err = client.Query("test_int > 64 and test_int < 128", nil)
+	res, err = client.Query(ctx, "test_int > 64 and test_int < 128", nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -125,7 +132,7 @@ func TestQuery(t *testing.T) {
 		return
 	}
 
-	res, err = client.Query("test_int > 123", nil)
+	res, err = client.Query(ctx, "test_int > 123", nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -135,7 +142,7 @@ func TestQuery(t *testing.T) {
 		return
 	}
 
-	res, err = client.Query("test_string = 'hello world'", nil)
+	res, err = client.Query(ctx, "test_string = 'hello world'", nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -152,6 +159,7 @@ func TestQuery(t *testing.T) {
 }
 
 func TestQueryMulti(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o1 := &types.Object{
 		Data: map[string]interface{}{
@@ -159,7 +167,7 @@ func TestQueryMulti(t *testing.T) {
 			"test_int": 1,
 		},
 	}
-	client.Set(o1, nil)
+	client.Set(ctx, o1, nil)
 
 	o2 := &types.Object{
 		Data: map[string]interface{}{
@@ -167,7 +175,7 @@ func TestQueryMulti(t *testing.T) {
 			"test_int": 99,
 		},
 	}
-	client.Set(o2, nil)
+	client.Set(ctx, o2, nil)
 
 	o3 := &types.Object{
 		Data: map[string]interface{}{
@@ -175,9 +183,9 @@ func TestQueryMulti(t *testing.T) {
 			"test_float": 153.4,
 		},
 	}
-	client.Set(o3, nil)
+	client.Set(ctx, o3, nil)
 
-	res, err := client.Query("test_int >= 1", nil)
+	res, err := client.Query(ctx, "test_int >= 1", nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -189,6 +197,7 @@ func TestQueryMulti(t *testing.T) {
 }
 
 func TestLargeIndex(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	// build very large index
 	for i := 0; i < 4096; i++ {
@@ -199,23 +208,24 @@ func TestLargeIndex(t *testing.T) {
 				"test_letter": string(byte(65 + (i % 24))),
 			},
 		}
-		client.Set(o, nil)
+		client.Set(ctx, o, nil)
 	}
 	index, _ := client.Index()
 	if len(index) != 4096 {
 		t.Error("unexpected index size")
 	}
-	res, _ := client.Query("test_int > 0", nil)
+	res, _ := client.Query(ctx, "test_int > 0", nil)
 	if len(res) == 0 {
 		t.Error("expected at least one result from query")
 	}
-	res, _ = client.Query("test_letter = 'A'", nil)
+	res, _ = client.Query(ctx, "test_letter = 'A'", nil)
 	if len(res) == 0 || len(res) == 4096 {
 		t.Error("expected more than one result from query but less than 4096")
 	}
 }
 
 func TestSyncIndex(t *testing.T) {
+	ctx := context.Background()
 	client := NewClient(nil)
 	o := &types.Object{
 		Data: map[string]interface{}{
@@ -225,13 +235,13 @@ func TestSyncIndex(t *testing.T) {
 			"test_string": "hello world",
 		},
 	}
-	if err := client.Set(o, nil); err != nil {
+	if err := client.Set(ctx, o, nil); err != nil {
 		t.Error(err)
 		return
 	}
 
 	// store object and sync index
-	client.Set(o, nil)
+	client.Set(ctx, o, nil)
 	if err := client.Sync(); err != nil {
 		t.Error(err)
 		return
@@ -239,7 +249,7 @@ func TestSyncIndex(t *testing.T) {
 
 	// update object without sync
 	o.Data["test_string"] = "hello world two"
-	client.Set(o, nil)
+	client.Set(ctx, o, nil)
 
 	// fetch remote index prior to sync to ensure
 	// old value still remains
@@ -261,3 +271,161 @@ func TestSyncIndex(t *testing.T) {
 	}
 
 }
+
+func TestQueryPage(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	for i := 0; i < 25; i++ {
+		o := &types.Object{
+			Data: map[string]interface{}{
+				"test_int": i,
+			},
+		}
+		if err := client.Set(ctx, o, nil); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	seen := make(map[string]bool)
+	after := ""
+	for {
+		page, _, next, err := client.QueryPage(ctx, "test_int >= 0", nil, 10, after)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, o := range page {
+			if seen[o.UID] {
+				t.Error("duplicate object across pages")
+			}
+			seen[o.UID] = true
+		}
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	if len(seen) != 25 {
+		t.Errorf("expected 25 objects across pages, got %d", len(seen))
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	for i := 0; i < 10; i++ {
+		o := &types.Object{
+			Data: map[string]interface{}{
+				"test_int": i,
+			},
+		}
+		if err := client.Set(ctx, o, nil); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	objects, errs := client.QueryStream(ctx, "test_int >= 0", nil)
+	count := 0
+	for range objects {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Error(err)
+		return
+	}
+	if count != 10 {
+		t.Errorf("expected 10 objects from stream, got %d", count)
+	}
+}
+
+func TestSetOpenBlob(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	sha256, size, err := client.SetBlob(ctx, bytes.NewReader([]byte("hello world")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if size != 11 {
+		t.Errorf("unexpected blob size %d", size)
+	}
+
+	rc, err := client.OpenBlob(ctx, sha256)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(data) != "hello world" {
+		t.Errorf("unexpected blob content %q", data)
+	}
+}
+
+func TestGCBlobs(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	sha256, _, err := client.SetBlob(ctx, bytes.NewReader([]byte("referenced")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, _, err := client.SetBlob(ctx, bytes.NewReader([]byte("orphaned"))); err != nil {
+		t.Error(err)
+		return
+	}
+
+	o := &types.Object{
+		Data: map[string]interface{}{"test": "hello"},
+		Blob: &types.BlobRef{SHA256: sha256, Size: 10},
+	}
+	if err := client.Set(ctx, o, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	removed, err := client.GCBlobs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 orphaned blob removed, got %d", removed)
+	}
+	if _, err := client.OpenBlob(ctx, sha256); err != nil {
+		t.Error("referenced blob should survive GC")
+	}
+}
+
+func TestDeleteGCsBlob(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	sha256, _, err := client.SetBlob(ctx, bytes.NewReader([]byte("blob data")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	o := &types.Object{
+		Data: map[string]interface{}{"test": "hello"},
+		Blob: &types.BlobRef{SHA256: sha256, Size: 9},
+	}
+	if err := client.Set(ctx, o, nil); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := client.Delete(ctx, o, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := client.OpenBlob(ctx, sha256); !errors.Is(err, ErrNotFound) {
+		t.Error("expected blob to be garbage collected by Delete")
+	}
+}
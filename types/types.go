@@ -0,0 +1,139 @@
+// Package types holds the data shapes shared across the store, service,
+// and transport (http/grpc) layers, so none of them need to depend on each
+// other's internals to exchange objects, users, and requests.
+package types
+
+import "time"
+
+// IndexValueMaxSize caps how many bytes of a string field are kept in the
+// index; longer values are still stored on the object itself, just not
+// indexed in full.
+const IndexValueMaxSize = 256
+
+// Object is a stored object: UID plus arbitrary Data, with bookkeeping
+// fields stamped by store.Client on Set.
+type Object struct {
+	UID      string
+	Data     map[string]interface{}
+	Created  time.Time
+	Author   string
+	Modified time.Time
+	Modifier string
+	Blob     *BlobRef
+}
+
+// API converts a stored Object into the map-of-fields shape the
+// transport layers send to callers, with reserved keys prefixed by "_".
+func (o *Object) API() APIObject {
+	api := make(APIObject, len(o.Data)+5)
+	for k, v := range o.Data {
+		api[k] = v
+	}
+	api["_uid"] = o.UID
+	if !o.Created.IsZero() {
+		api["_created"] = o.Created.UTC().Format(time.RFC3339)
+	}
+	if o.Author != "" {
+		api["_author"] = o.Author
+	}
+	if !o.Modified.IsZero() {
+		api["_modified"] = o.Modified.UTC().Format(time.RFC3339)
+	}
+	if o.Modifier != "" {
+		api["_modifier"] = o.Modifier
+	}
+	return api
+}
+
+// APIObject is an object coming from (or going to) a transport layer: the
+// reserved "_uid"/"_created"/"_author"/"_modified"/"_modifier" keys plus
+// arbitrary data fields.
+type APIObject map[string]interface{}
+
+// Object splits an APIObject back into the reserved UID and the data
+// fields store.Client persists.
+func (o *APIObject) Object() *Object {
+	data := make(map[string]interface{})
+	for k, v := range *o {
+		switch k {
+		case "_uid", "_created", "_author", "_modified", "_modifier":
+		default:
+			data[k] = v
+		}
+	}
+	return &Object{
+		UID:  (*o).UID(),
+		Data: data,
+	}
+}
+
+// UID returns the object's "_uid" field, or "" if unset.
+func (o *APIObject) UID() string {
+	if uid, ok := (*o)["_uid"]; ok {
+		if s, ok := uid.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// IndexObject is the reduced, index-sized projection of an Object kept in
+// store.Client's index: same UID and Data, but with long string values
+// truncated to IndexValueMaxSize.
+type IndexObject struct {
+	UID  string
+	Data map[string]interface{}
+}
+
+// BlobRef links an Object to a content-addressed blob held out-of-line by
+// the store.
+type BlobRef struct {
+	SHA256      string
+	Size        int64
+	ContentType string
+}
+
+// User is an authenticatable account.
+type User struct {
+	UID          string
+	Username     string
+	PasswordHash string
+	Groups       []string
+}
+
+// APIResource names the kind of operation an APIRequest performs.
+type APIResource string
+
+// The set of resources the API supports.
+const (
+	APILogin  APIResource = "login"
+	APIGet    APIResource = "get"
+	APISet    APIResource = "set"
+	APIDelete APIResource = "delete"
+	APIQuery  APIResource = "query"
+)
+
+// APIRequest is the decoded form of a request body against the legacy
+// (unversioned) HTTP API.
+type APIRequest struct {
+	SessionKey string      `json:"key"`
+	Username   string      `json:"username"`
+	Password   string      `json:"password"`
+	IP         string      `json:"-"`
+	Objects    []APIObject `json:"objects"`
+	Query      string      `json:"query"`
+	Limit      int         `json:"limit"`
+	After      string      `json:"after"`
+	Deadline   int         `json:"deadline"`
+}
+
+// APIResponse is the legacy (unversioned) HTTP API's response envelope.
+type APIResponse struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Objects    []APIObject `json:"objects,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Key        string      `json:"key,omitempty"`
+	Expires    string      `json:"expires,omitempty"`
+	XSRFToken  string      `json:"xsrf_token,omitempty"`
+}